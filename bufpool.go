@@ -0,0 +1,186 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sync
+
+import (
+	"math/bits"
+	"sync/atomic"
+)
+
+// DefMaxLength is the default upper bound on the capacity of a []byte that
+// BufferPool will retain in Put. Slices larger than this are dropped so a
+// single oversized buffer cannot pin memory in the pool indefinitely.
+const DefMaxLength = 1 << 32
+
+// bufPoolBuckets is the number of power-of-two size classes, covering
+// buffers from 1 byte up to 1<<31 bytes.
+const bufPoolBuckets = 32
+
+// bufPoolSizeLimit is one past the largest size bufPoolFloor/bufPoolIndex
+// can represent without wrapping: they work in uint32, so a size at or
+// above 1<<32 must never reach them. bufPoolCeil wraps to 0 for any size
+// above 1<<(bufPoolBuckets-1), so Get and GetN check against that smaller
+// bound instead. Callers are expected to check before rounding, not after.
+const bufPoolSizeLimit = 1 << 32
+
+// A BufferPool is a size-bucketed pool of []byte values built on top of
+// Pools. It rounds requested sizes up to the next power of two and keeps a
+// separate Pools for each power-of-two length, so Get(n) and Put always
+// operate on buffers that can satisfy requests of that class.
+//
+// A BufferPool is safe for use by multiple goroutines simultaneously, and
+// the zero value is ready to use.
+type BufferPool struct {
+	pools [bufPoolBuckets]Pools
+	stats [bufPoolBuckets]bufPoolClassCounters
+
+	// New optionally specifies a function to allocate a buffer of the
+	// given size when Get would otherwise have to grow one. It may not
+	// be changed concurrently with calls to Get. If nil, make([]byte, 0, size)
+	// is used.
+	New func(size int) []byte
+
+	// MinLength bounds the smallest size class BufferPool maintains. Get
+	// requests smaller than MinLength are rounded up to it, and Put drops
+	// buffers smaller than MinLength instead of pooling them under an
+	// oversized class. Zero means no lower bound (1 byte).
+	MinLength int
+
+	// MaxLength bounds the capacity of buffers retained by Put. Buffers
+	// whose cap exceeds MaxLength are dropped instead of pooled. Zero
+	// means DefMaxLength.
+	MaxLength int
+}
+
+// bufPoolClassCounters are the atomic per-size-class counters backing
+// BufferPool.Stats.
+type bufPoolClassCounters struct {
+	hits   int64
+	misses int64
+	allocs int64
+}
+
+// A BufferPoolStats reports the counters for one size class of a
+// BufferPool, letting operators tune MinLength/MaxLength from observed hit
+// rates instead of guessing.
+type BufferPoolStats struct {
+	Size int // the size class, in bytes (a power of two)
+
+	Hits   int64 // Get calls satisfied by a pooled buffer of this class
+	Misses int64 // Get calls that found this class empty
+
+	// Allocs counts buffers allocated for this class. It currently always
+	// equals Misses, since every miss allocates exactly one buffer; it is
+	// tracked separately so a future New hook that sometimes supplies a
+	// buffer without a fresh allocation doesn't have to change this API.
+	Allocs int64
+}
+
+// Stats returns per-size-class counters for every power-of-two class
+// BufferPool maintains, in increasing size order.
+func (b *BufferPool) Stats() []BufferPoolStats {
+	out := make([]BufferPoolStats, bufPoolBuckets)
+	for i := range out {
+		out[i] = BufferPoolStats{
+			Size:   1 << uint(i),
+			Hits:   atomic.LoadInt64(&b.stats[i].hits),
+			Misses: atomic.LoadInt64(&b.stats[i].misses),
+			Allocs: atomic.LoadInt64(&b.stats[i].allocs),
+		}
+	}
+	return out
+}
+
+// Get returns a []byte with length 0 and capacity at least n, reusing a
+// pooled buffer from the smallest size class that fits n when one is
+// available, or allocating a new one otherwise. Requests smaller than
+// MinLength are rounded up to it first.
+func (b *BufferPool) Get(n int) []byte {
+	if n <= 0 {
+		n = 1
+	}
+	if min := b.MinLength; min > 0 && n < min {
+		n = min
+	}
+	if n > 1<<(bufPoolBuckets-1) {
+		return b.newBuf(n)
+	}
+	idx := bufPoolIndex(bufPoolCeil(n))
+	if idx >= bufPoolBuckets {
+		return b.newBuf(n)
+	}
+	if x := b.pools[idx].Get(); x != nil {
+		atomic.AddInt64(&b.stats[idx].hits, 1)
+		return x.([]byte)[:0]
+	}
+	atomic.AddInt64(&b.stats[idx].misses, 1)
+	atomic.AddInt64(&b.stats[idx].allocs, 1)
+	return b.newBuf(1 << uint(idx))
+}
+
+// Put returns buf to the pool so a later Get can reuse its backing array.
+// buf is bucketed by its capacity rounded down to the previous power of
+// two, so it is only ever handed back to requests it can satisfy. Buffers
+// outside the configured [MinLength, MaxLength] range (DefMaxLength if
+// MaxLength is zero, no lower bound if MinLength is zero) are dropped
+// instead of pooled.
+func (b *BufferPool) Put(buf []byte) {
+	c := cap(buf)
+	if c == 0 {
+		return
+	}
+	max := b.MaxLength
+	if max <= 0 {
+		max = DefMaxLength
+	}
+	if c > max {
+		return
+	}
+	if min := b.MinLength; min > 0 && c < min {
+		return
+	}
+	if c >= bufPoolSizeLimit {
+		return
+	}
+	idx := bufPoolIndex(bufPoolFloor(c))
+	if idx >= bufPoolBuckets {
+		return
+	}
+	b.pools[idx].Put(buf)
+}
+
+func (b *BufferPool) newBuf(size int) []byte {
+	if b.New != nil {
+		return b.New(size)
+	}
+	return make([]byte, 0, size)
+}
+
+// bufPoolCeil rounds v up to the next power of two using bit-twiddling.
+func bufPoolCeil(v int) uint32 {
+	u := uint32(v)
+	u--
+	u |= u >> 1
+	u |= u >> 2
+	u |= u >> 4
+	u |= u >> 8
+	u |= u >> 16
+	u++
+	return u
+}
+
+// bufPoolFloor rounds v down to the previous power of two.
+func bufPoolFloor(v int) uint32 {
+	u := uint32(v)
+	return 1 << uint(bits.Len32(u)-1)
+}
+
+// bufPoolIndex returns the bucket index for a power-of-two size v.
+func bufPoolIndex(v uint32) int {
+	if v == 0 {
+		return 0
+	}
+	return bits.Len32(v - 1)
+}
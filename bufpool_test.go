@@ -0,0 +1,127 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Pools is no-op under race detector, so all these tests do not work.
+// +build !race
+
+package sync_test
+
+import (
+	. "sync"
+	"testing"
+)
+
+// reused reports whether buf's backing array looks like it came from the
+// pool rather than a fresh make(): mark stamps a marker byte into the
+// backing array (beyond buf's returned length, within its cap), which a
+// freshly allocated (and therefore zeroed) buffer would never have.
+func reused(buf []byte) bool {
+	return cap(buf) > 0 && buf[:cap(buf)][0] == 0xAB
+}
+
+func mark(buf []byte) []byte {
+	full := buf[:cap(buf)]
+	full[0] = 0xAB
+	return buf
+}
+
+func TestBufferPoolGetPut(t *testing.T) {
+	var b BufferPool
+	buf := b.Get(100)
+	if len(buf) != 0 || cap(buf) < 100 {
+		t.Fatalf("got len=%d cap=%d; want len=0 cap>=100", len(buf), cap(buf))
+	}
+	buf = mark(append(buf, make([]byte, 100)...))
+	b.Put(buf)
+
+	// A same-size request is satisfied from the pool.
+	again := b.Get(100)
+	if !reused(again) {
+		t.Fatalf("expected a Get(100) following Put of a cap-%d buffer to reuse it", cap(buf))
+	}
+}
+
+func TestBufferPoolRoundsUpToPowerOfTwo(t *testing.T) {
+	var b BufferPool
+	buf := b.Get(5)
+	if cap(buf) != 8 {
+		t.Fatalf("got cap=%d; want 8", cap(buf))
+	}
+	buf = b.Get(1)
+	if cap(buf) != 1 {
+		t.Fatalf("got cap=%d; want 1", cap(buf))
+	}
+}
+
+func TestBufferPoolPutRoundsDown(t *testing.T) {
+	var b BufferPool
+	buf := mark(make([]byte, 0, 10)) // cap 10 rounds down to the size-8 class
+	b.Put(buf)
+	if g := b.Get(16); reused(g) {
+		t.Fatalf("expected a Get(16) (size-16 class) to not reuse a buffer put in at cap 10 (size-8 class)")
+	}
+	if g := b.Get(8); !reused(g) {
+		t.Fatalf("expected a Get(8) (size-8 class) to reuse the pooled cap-10 buffer")
+	}
+}
+
+func TestBufferPoolMaxLength(t *testing.T) {
+	b := BufferPool{MaxLength: 16}
+	big := mark(make([]byte, 0, 32))
+	b.Put(big)
+	if g := b.Get(32); reused(g) {
+		t.Fatalf("expected an oversized (cap 32 > MaxLength 16) buffer to be dropped, not pooled")
+	}
+}
+
+func TestBufferPoolMinLength(t *testing.T) {
+	b := BufferPool{MinLength: 64}
+	small := mark(make([]byte, 0, 8))
+	b.Put(small)
+	if g := b.Get(8); reused(g) {
+		t.Fatalf("expected a below-MinLength (cap 8 < 64) buffer to be dropped, not pooled")
+	}
+	// A Get below MinLength is itself rounded up to it.
+	if g := b.Get(8); cap(g) < 64 {
+		t.Fatalf("got cap=%d; want >=64 (rounded up to MinLength)", cap(g))
+	}
+}
+
+func TestBufferPoolStats(t *testing.T) {
+	var b BufferPool
+	b.Get(16)                     // miss, allocates a cap-16 buffer
+	b.Put(mark(make([]byte, 16))) // pools a second cap-16 buffer
+	b.Get(16)                     // hit, reuses the buffer just put
+
+	stats := b.Stats()
+	const idx = 4 // 1<<4 == 16
+	if got := stats[idx]; got.Size != 16 || got.Hits != 1 || got.Misses != 1 || got.Allocs != 1 {
+		t.Fatalf("got %+v; want {Size:16 Hits:1 Misses:1 Allocs:1}", got)
+	}
+}
+
+func TestBufferPoolNewHook(t *testing.T) {
+	calls := 0
+	b := BufferPool{New: func(size int) []byte {
+		calls++
+		return make([]byte, 0, size*2)
+	}}
+	buf := b.Get(10)
+	if calls != 1 {
+		t.Fatalf("got %d calls; want 1", calls)
+	}
+	if cap(buf) != 32 {
+		t.Fatalf("got cap=%d; want 32", cap(buf))
+	}
+}
+
+func BenchmarkBufferPoolGetPut(b *testing.B) {
+	var bp BufferPool
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			buf := bp.Get(512)
+			bp.Put(buf)
+		}
+	})
+}
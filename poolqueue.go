@@ -0,0 +1,269 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sync
+
+import (
+	"sync/atomic"
+	"unsafe"
+)
+
+// poolsDequeue is a lock-free fixed-size single-producer, multi-consumer
+// queue. The owning P pushes and pops from the head; any other P steals
+// from the tail. It is used in place of a Mutex-protected slice for the
+// "shared" portion of a poolsLocal, so getSlow/getSlows steal without
+// taking a lock, and a full ring never needs its elements zeroed one by
+// one under STW.
+//
+// poolsDequeue is a fork of the dequeue used by the standard library's own
+// sync.Pool.
+type poolsDequeue struct {
+	// headTail packs a 32-bit head index and a 32-bit tail index, both
+	// mod len(vals). tail is the oldest data in the queue, and head is
+	// the next slot to fill.
+	//
+	// head and tail are only 32 bits because the ring is never grown
+	// past poolsDequeueLimit entries (chains of these are used for that
+	// instead); this keeps the pair updatable with a single
+	// atomic.Add/CompareAndSwap on platforms without 128-bit atomics.
+	headTail uint64
+
+	// vals is a ring buffer of interface{} values boxed as
+	// unsafe.Pointer, sized to a power of two. A slot is nil if it is
+	// empty and otherwise points to a boxed interface{} value.
+	vals []unsafe.Pointer
+}
+
+const poolsDequeueBits = 32
+
+// poolsDequeueLimit bounds the size of a single poolsDequeue. Chains of these
+// (see poolsChain) are used for queues that must grow past this.
+const poolsDequeueLimit = (1 << poolsDequeueBits) / 4
+
+func (d *poolsDequeue) unpack(ptrs uint64) (head, tail uint32) {
+	const mask = 1<<poolsDequeueBits - 1
+	head = uint32((ptrs >> poolsDequeueBits) & mask)
+	tail = uint32(ptrs & mask)
+	return
+}
+
+func (d *poolsDequeue) pack(head, tail uint32) uint64 {
+	const mask = 1<<poolsDequeueBits - 1
+	return uint64(head)<<poolsDequeueBits | uint64(tail&mask)
+}
+
+// pushHead adds val at the head of the queue. It is only safe to call by
+// the single producer (the owning P). It reports whether the push
+// succeeded; it fails if the queue is full.
+func (d *poolsDequeue) pushHead(val interface{}) bool {
+	ptrs := atomic.LoadUint64(&d.headTail)
+	head, tail := d.unpack(ptrs)
+	if (tail+uint32(len(d.vals)))&(1<<poolsDequeueBits-1) == head {
+		// Queue is full.
+		return false
+	}
+	slot := &d.vals[head&uint32(len(d.vals)-1)]
+
+	// Check if the head slot has been released by popTail.
+	typ := atomic.LoadPointer(slot)
+	if typ != nil {
+		// Another goroutine is still cleaning up the tail, so the queue
+		// is actually still full.
+		return false
+	}
+
+	box := new(interface{})
+	*box = val
+	atomic.StorePointer(slot, unsafe.Pointer(box))
+
+	// Increment head. This passes ownership of slot to popTail and acts
+	// as a store barrier for the write above.
+	atomic.AddUint64(&d.headTail, 1<<poolsDequeueBits)
+	return true
+}
+
+// popHead removes and returns an element from the head of the queue. It
+// is only safe to call by the single producer (the owning P). It reports
+// false if the queue is empty. When one element is left, popHead and a
+// concurrent popTail target the same slot; the CAS on headTail decides
+// which of them takes it.
+func (d *poolsDequeue) popHead() (interface{}, bool) {
+	var slot *unsafe.Pointer
+	for {
+		ptrs := atomic.LoadUint64(&d.headTail)
+		head, tail := d.unpack(ptrs)
+		if tail == head {
+			// Queue is empty.
+			return nil, false
+		}
+		head--
+		ptrs2 := d.pack(head, tail)
+		if atomic.CompareAndSwapUint64(&d.headTail, ptrs, ptrs2) {
+			slot = &d.vals[head&uint32(len(d.vals)-1)]
+			break
+		}
+	}
+
+	val := *(*interface{})(atomic.LoadPointer(slot))
+	atomic.StorePointer(slot, nil)
+	return val, true
+}
+
+// popTail removes and returns an element from the tail of the queue. It
+// can be called by any P (including the owner). It reports false if the
+// queue is empty.
+func (d *poolsDequeue) popTail() (interface{}, bool) {
+	var slot *unsafe.Pointer
+	for {
+		ptrs := atomic.LoadUint64(&d.headTail)
+		head, tail := d.unpack(ptrs)
+		if tail == head {
+			// Queue is empty.
+			return nil, false
+		}
+		ptrs2 := d.pack(head, tail+1)
+		if atomic.CompareAndSwapUint64(&d.headTail, ptrs, ptrs2) {
+			slot = &d.vals[tail&uint32(len(d.vals)-1)]
+			break
+		}
+	}
+
+	// Wait for the slot to be non-nil. pushHead stores the slot before it
+	// advances head, so a slot reached through the CAS above is normally
+	// already written.
+	var val unsafe.Pointer
+	for {
+		val = atomic.LoadPointer(slot)
+		if val != nil {
+			break
+		}
+		runtime_procPin()
+		runtime_procUnpin()
+	}
+	x := *(*interface{})(val)
+	atomic.StorePointer(slot, nil)
+	return x, true
+}
+
+// len returns an approximate count of items currently in the dequeue. It
+// is intended for diagnostics (see Pools.Stats) and may be stale relative
+// to concurrent pushes/pops.
+func (d *poolsDequeue) len() int {
+	ptrs := atomic.LoadUint64(&d.headTail)
+	head, tail := d.unpack(ptrs)
+	return int(head - tail)
+}
+
+// poolsChainElt is one link of a poolsChain.
+type poolsChainElt struct {
+	poolsDequeue
+
+	// next and prev link to the adjacent poolsChainElts in this
+	// poolsChain. next is written atomically by the producer and read
+	// atomically by consumers looking for the next dequeue to steal
+	// from. prev is only written by the producer; poolsChain.len also
+	// reads it, without synchronization.
+	next, prev unsafe.Pointer // *poolsChainElt
+}
+
+// poolsChain is a dynamically-sized version of poolsDequeue. It is only
+// safe for a single producer (pushHead/popHead) but any number of
+// consumers may call popTail.
+type poolsChain struct {
+	// head is the poolsChainElt to push to. Only written by the
+	// producer, so it isn't synchronized; poolsChain.len (used by
+	// Pools.Stats) also reads it from other goroutines.
+	head *poolsChainElt
+
+	// tail is the poolsChainElt to popTail from. Accessed by consumers,
+	// so reads and writes must be atomic.
+	tail unsafe.Pointer // *poolsChainElt
+}
+
+func storePoolsChainElt(pp *unsafe.Pointer, v *poolsChainElt) {
+	atomic.StorePointer(pp, unsafe.Pointer(v))
+}
+
+func loadPoolsChainElt(pp *unsafe.Pointer) *poolsChainElt {
+	return (*poolsChainElt)(atomic.LoadPointer(pp))
+}
+
+func (c *poolsChain) pushHead(val interface{}) {
+	d := c.head
+	if d == nil {
+		// Initialize the chain.
+		const initSize = 8 // must be a power of two
+		d = new(poolsChainElt)
+		d.vals = make([]unsafe.Pointer, initSize)
+		c.head = d
+		storePoolsChainElt(&c.tail, d)
+	}
+
+	if d.pushHead(val) {
+		return
+	}
+
+	// The current dequeue is full. Allocate a new one of twice the size.
+	newSize := len(d.vals) * 2
+	if newSize >= poolsDequeueLimit {
+		newSize = poolsDequeueLimit
+	}
+
+	d2 := &poolsChainElt{prev: unsafe.Pointer(d)}
+	d2.vals = make([]unsafe.Pointer, newSize)
+	c.head = d2
+	storePoolsChainElt(&d.next, d2)
+	d2.pushHead(val)
+}
+
+func (c *poolsChain) popHead() (interface{}, bool) {
+	d := c.head
+	for d != nil {
+		if val, ok := d.popHead(); ok {
+			return val, ok
+		}
+		// There may still be unconsumed elements in the prior dequeue,
+		// so try backing up.
+		d = (*poolsChainElt)(d.prev)
+	}
+	return nil, false
+}
+
+// len returns an approximate total count of items across every dequeue
+// still linked into the chain. Like poolsDequeue.len, it is intended for
+// diagnostics and may be stale relative to concurrent pushes/pops.
+func (c *poolsChain) len() int {
+	n := 0
+	for d := c.head; d != nil; d = (*poolsChainElt)(d.prev) {
+		n += d.len()
+	}
+	return n
+}
+
+func (c *poolsChain) popTail() (interface{}, bool) {
+	d := loadPoolsChainElt(&c.tail)
+	if d == nil {
+		return nil, false
+	}
+
+	for {
+		d2 := loadPoolsChainElt(&d.next)
+
+		if val, ok := d.popTail(); ok {
+			return val, ok
+		}
+
+		if d2 == nil {
+			// This is the only dequeue. It's empty right now, but could
+			// be pushed into in the future.
+			return nil, false
+		}
+
+		// The tail of the chain has been drained, so move on to the
+		// next dequeue. Try to drop it from the chain so the next
+		// poller doesn't have to re-check it.
+		storePoolsChainElt(&c.tail, d2)
+		d = d2
+	}
+}
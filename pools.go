@@ -40,30 +40,166 @@ import (
 // free list.
 //
 
-var (
-	DefPoolsPrivateSize = 1024
-)
+// DefPoolsPrivateSize is unused: poolsLocal.private is now a fixed-size
+// [poolLocalCap]interface{} ring rather than an append-grown slice, so there
+// is no longer a configurable default to fall back to. Kept for source
+// compatibility with callers that still reference it.
+//
+// Deprecated: has no effect.
+var DefPoolsPrivateSize = 1024
 
 type Pools struct {
 	local     unsafe.Pointer // local fixed-size per-P pool, actual type is [P]poolsLocal
 	localSize uintptr        // size of the local array
 
+	victim     unsafe.Pointer // local from previous cycle, actual type is [P]poolsLocal
+	victimSize uintptr        // size of victims array
+
+	// PrivateSize no longer has any effect: the per-P private buffer is a
+	// fixed-size [poolLocalCap]interface{} ring now, not an append-grown
+	// slice, so there is nothing left to configure a threshold for. Kept
+	// for source compatibility with existing callers.
+	//
+	// Deprecated: has no effect.
+	PrivateSize int
+
 	// New optionally specifies a function to generate
 	// a value when Get would otherwise return nil.
 	// It may not be changed concurrently with calls to Get.
-	PrivateSize int
-	New         func() interface{}
+	New func() interface{}
+
+	// NewN optionally specifies a function to generate a value of the
+	// requested size when GetN would otherwise return nil. It may not be
+	// changed concurrently with calls to GetN.
+	NewN func(size int) interface{}
+
+	ns [bufPoolBuckets]unsafe.Pointer // lazily-initialized *Pools sub-pools used by GetN/PutN
+
+	// MaxSize, if positive, bounds the number of items the Pools will
+	// retain. Put/Puts calls that would push the total past MaxSize hand
+	// the surplus items to OnEvict instead of retaining them, and items
+	// still held when the Pools is drained by GC are also passed to
+	// OnEvict before their references are dropped. This turns Pools into
+	// a viable pool for resources with real close/cleanup semantics
+	// (file handles, cgo-backed buffers, decompressor state), where
+	// silently losing a reference would leak the underlying resource.
+	MaxSize int
+	OnEvict func(interface{})
+
+	// OnNew, if non-nil, is invoked outside the pinned region each time
+	// Get or Gets falls through to New because the pool had nothing to
+	// offer. It receives the value New produced.
+	OnNew func(interface{})
+
+	size int32 // atomic: approximate count of items currently retained
+
+	// Usage counters backing Stats. All are approximate under concurrent
+	// access (loaded/added independently of one another), which is fine
+	// for the diagnostic purpose they serve.
+	statGets     int64
+	statPuts     int64
+	statHits     int64
+	statMisses   int64
+	statSteals   int64
+	statNewCalls int64
+}
+
+// A Stats is a point-in-time snapshot of a Pools' usage counters, returned
+// by (*Pools).Stats.
+type Stats struct {
+	Gets int64 // calls to Get, plus one per item requested via Gets
+	Puts int64 // items retained by Put/Puts (items handed to OnEvict via MaxSize don't count)
+
+	Hits   int64 // Gets satisfied by a pooled item, from private, shared, or the victim cache
+	Misses int64 // Gets that found nothing pooled and fell through to New (or returned nil/zero)
+
+	// Steals counts Hits that came from another P's shared chain or from
+	// the victim cache, rather than the calling goroutine's own private
+	// buffer or shared chain.
+	Steals int64
+
+	NewCalls int64 // calls made to New
+
+	// PrivateLen and SharedLen are computed by walking the live per-P
+	// locals and the victim generation at call time, so
+	// they may be stale the instant they are read under concurrent
+	// Put/Get.
+	PrivateLen int
+	SharedLen  int
 }
 
+// Stats returns a snapshot of p's usage counters.
+func (p *Pools) Stats() Stats {
+	s := Stats{
+		Gets:     atomic.LoadInt64(&p.statGets),
+		Puts:     atomic.LoadInt64(&p.statPuts),
+		Hits:     atomic.LoadInt64(&p.statHits),
+		Misses:   atomic.LoadInt64(&p.statMisses),
+		Steals:   atomic.LoadInt64(&p.statSteals),
+		NewCalls: atomic.LoadInt64(&p.statNewCalls),
+	}
+	size := atomic.LoadUintptr(&p.localSize)
+	local := p.local
+	for i := 0; i < int(size); i++ {
+		l := indexLocals(local, i)
+		s.PrivateLen += l.privateLen
+		s.SharedLen += l.shared.len()
+	}
+	vsize := atomic.LoadUintptr(&p.victimSize)
+	vlocal := p.victim
+	for i := 0; i < int(vsize); i++ {
+		l := indexLocals(vlocal, i)
+		s.PrivateLen += l.privateLen
+		s.SharedLen += l.shared.len()
+	}
+	return s
+}
+
+// cacheLineSize is the assumed CPU cache line size, used to size
+// poolsLocal.private so that it fits within two cache lines without any
+// extra blind padding.
+const cacheLineSize = 64
+
+// poolLocalCap is the number of items poolsLocal.private can hold before
+// Put/Puts must spill to shared. It is sized so the fixed private ring
+// occupies about two cache lines, the same budget the previous pad [128]byte
+// field reserved, but actually used to hold items instead of sitting idle.
+const poolLocalCap = 2*cacheLineSize/int(unsafe.Sizeof(interface{}(nil))) - 1
+
+// poolsLocalFixedSize is the size, in bytes, of a poolsLocal's private,
+// privateLen, and shared fields combined (everything but the trailing pad
+// below).
+const poolsLocalFixedSize = poolLocalCap*int(unsafe.Sizeof(interface{}(nil))) +
+	int(unsafe.Sizeof(int(0))) +
+	int(unsafe.Sizeof(poolsChain{}))
+
+// poolsLocalPad rounds poolsLocal up to a whole number of cache lines, so
+// that adjacent elements of a []poolsLocal never share a cache line and
+// false-share under concurrent access from different Ps. This is the same
+// role the previous pad [128]byte field played; it is computed here instead
+// of hand-picked so it stays correct if poolLocalCap ever changes.
+const poolsLocalPad = (cacheLineSize - poolsLocalFixedSize%cacheLineSize) % cacheLineSize
+
 // Local per-P Pools appendix.
+//
+// private is a fixed-size LIFO ring, touched only by the owning P (outside
+// of drains/steals done with the world stopped), so Put/Get never grow or
+// shrink a slice on the hot path the way append-based private did. shared is
+// a poolsChain: the owning P pushes and pops its head without contention, and
+// other Ps (and the victim-cache drain) steal from its tail with a lock-free
+// CAS instead of a Mutex, so getSlow/getSlows steal without taking a
+// lock. pad rounds the whole struct up to a cache-line
+// multiple so adjacent Ps' entries in a []poolsLocal don't false-share.
 type poolsLocal struct {
-	private []interface{} // Can be used only by the respective P.
-	shared  []interface{} // Can be used by any P.
-	Mutex                 // Protects shared.
-	pad     [128]byte     // Prevents false sharing.
+	private    [poolLocalCap]interface{} // Fixed-size LIFO buffer, used only by the respective P.
+	privateLen int                       // number of valid entries in private[:privateLen]
+	shared     poolsChain                 // Popped head-first by the owner, tail-first by stealers.
+	pad        [poolsLocalPad]byte
 }
 
-// Put adds x to the pool.
+// Put adds x to the pool. If p.MaxSize is positive and the pool is already
+// at capacity, x is passed to p.OnEvict (if non-nil) instead of being
+// retained.
 func (p *Pools) Put(x interface{}) {
 	if raceenabled {
 		// Under race detector the Pools degenerates into no-op.
@@ -74,22 +210,30 @@ func (p *Pools) Put(x interface{}) {
 	if x == nil {
 		return
 	}
-	l := p.pin()
-	if n := len(l.private) + 1; (p.PrivateSize > 0 && n <= p.PrivateSize) ||
-		(p.PrivateSize == 0 && n <= DefPoolsPrivateSize) {
-		l.private = append(l.private, x)
-		x = nil
+	if p.MaxSize > 0 {
+		if atomic.AddInt32(&p.size, 1) > int32(p.MaxSize) {
+			atomic.AddInt32(&p.size, -1)
+			if p.OnEvict != nil {
+				p.OnEvict(x)
+			}
+			return
+		}
 	}
-	runtime_procUnpin()
-	if x == nil {
+	atomic.AddInt64(&p.statPuts, 1)
+	l := p.pin()
+	if l.privateLen < len(l.private) {
+		l.private[l.privateLen] = x
+		l.privateLen++
+		runtime_procUnpin()
 		return
 	}
-	l.Lock()
-	l.shared = append(l.shared, x)
-	l.Unlock()
+	l.shared.pushHead(x)
+	runtime_procUnpin()
 }
 
-// Put adds xs to the pool.
+// Puts adds xs to the pool. If p.MaxSize is positive, any xs beyond
+// available capacity are passed to p.OnEvict (if non-nil) instead of
+// being retained.
 func (p *Pools) Puts(xs []interface{}) {
 	if raceenabled {
 		// Under race detector the Pools degenerates into no-op.
@@ -101,19 +245,101 @@ func (p *Pools) Puts(xs []interface{}) {
 	if xsl == 0 {
 		return
 	}
+	if p.MaxSize > 0 {
+		n := atomic.AddInt32(&p.size, int32(xsl))
+		if over := n - int32(p.MaxSize); over > 0 {
+			if over > int32(xsl) {
+				over = int32(xsl)
+			}
+			atomic.AddInt32(&p.size, -over)
+			keep := xsl - int(over)
+			evict := xs[keep:]
+			xs = xs[:keep]
+			xsl = keep
+			if p.OnEvict != nil {
+				for _, x := range evict {
+					p.OnEvict(x)
+				}
+			}
+			if xsl == 0 {
+				return
+			}
+		}
+	}
+	atomic.AddInt64(&p.statPuts, int64(xsl))
 	l := p.pin()
-	if n := len(l.private) + xsl; (p.PrivateSize > 0 && n <= p.PrivateSize) ||
-		(p.PrivateSize == 0 && n <= DefPoolsPrivateSize) {
-		l.private = append(l.private, xs...)
-		xs = nil
+	for len(xs) > 0 && l.privateLen < len(l.private) {
+		l.private[l.privateLen] = xs[0]
+		l.privateLen++
+		xs = xs[1:]
+	}
+	for _, x := range xs {
+		l.shared.pushHead(x)
 	}
 	runtime_procUnpin()
-	if xs == nil {
+}
+
+// GetN selects an arbitrary item from the sub-pool holding items of at
+// least size bytes, removes it, and returns it to the caller. size is
+// rounded up to the next power of two to pick one of 32 internal
+// power-of-two sub-pools (see PutN), so items returned by GetN only ever
+// satisfy requests they are large enough for.
+//
+// If the chosen sub-pool is empty and p.NewN is non-nil, GetN returns the
+// result of calling p.NewN(size).
+func (p *Pools) GetN(size int) interface{} {
+	if size > 1<<(bufPoolBuckets-1) {
+		if p.NewN != nil {
+			return p.NewN(size)
+		}
+		return nil
+	}
+	idx := bufPoolIndex(bufPoolCeil(size))
+	if idx >= bufPoolBuckets {
+		if p.NewN != nil {
+			return p.NewN(size)
+		}
+		return nil
+	}
+	if x := p.subPool(idx).Get(); x != nil {
+		return x
+	}
+	if p.NewN != nil {
+		return p.NewN(size)
+	}
+	return nil
+}
+
+// PutN adds x to the sub-pool for items of at most size bytes. size is
+// rounded down to the previous power of two, so x is only ever handed
+// back to a GetN call it can satisfy.
+func (p *Pools) PutN(size int, x interface{}) {
+	if x == nil || size <= 0 {
+		return
+	}
+	if size >= bufPoolSizeLimit {
 		return
 	}
-	l.Lock()
-	l.shared = append(l.shared, xs...)
-	l.Unlock()
+	idx := bufPoolIndex(bufPoolFloor(size))
+	if idx >= bufPoolBuckets {
+		return
+	}
+	p.subPool(idx).Put(x)
+}
+
+// subPool lazily allocates the Pools backing GetN/PutN bucket idx.
+func (p *Pools) subPool(idx int) *Pools {
+	if sp := (*Pools)(atomic.LoadPointer(&p.ns[idx])); sp != nil {
+		return sp
+	}
+	allPoolxsMu.Lock()
+	defer allPoolxsMu.Unlock()
+	if sp := (*Pools)(atomic.LoadPointer(&p.ns[idx])); sp != nil {
+		return sp
+	}
+	sp := &Pools{}
+	atomic.StorePointer(&p.ns[idx], unsafe.Pointer(sp))
+	return sp
 }
 
 // Get selects an arbitrary item from the Pools, removes it from the
@@ -125,34 +351,60 @@ func (p *Pools) Puts(xs []interface{}) {
 // If Get would otherwise return nil and p.New is non-nil, Get returns
 // the result of calling p.New.
 func (p *Pools) Get() interface{} {
+	atomic.AddInt64(&p.statGets, 1)
 	if raceenabled {
 		if p.New != nil {
-			return p.New()
+			return p.newValue()
 		}
+		atomic.AddInt64(&p.statMisses, 1)
 		return nil
 	}
 	l := p.pin()
 	var x interface{}
-	if n := len(l.private); n > 0 {
-		x = l.private[n-1]
-		l.private = l.private[:n-1]
+	if n := l.privateLen; n > 0 {
+		n--
+		x = l.private[n]
+		l.private[n] = nil
+		l.privateLen = n
+	}
+	if x == nil {
+		x, _ = l.shared.popHead()
 	}
-
 	runtime_procUnpin()
 	if x != nil {
+		p.decSize()
+		atomic.AddInt64(&p.statHits, 1)
 		return x
 	}
-	l.Lock()
-	last := len(l.shared) - 1
-	if last >= 0 {
-		x = l.shared[last]
-		l.shared = l.shared[:last]
+	return p.getSlow()
+}
+
+// newValue calls p.New (if set), recording the Miss/NewCalls stats and
+// invoking OnNew, and returns the result (nil if New is unset).
+func (p *Pools) newValue() interface{} {
+	atomic.AddInt64(&p.statMisses, 1)
+	if p.New == nil {
+		return nil
+	}
+	atomic.AddInt64(&p.statNewCalls, 1)
+	x := p.New()
+	if p.OnNew != nil {
+		p.OnNew(x)
 	}
-	l.Unlock()
-	if x != nil {
-		return x
+	return x
+}
+
+// decSize accounts for an item leaving the pool towards a caller, so a
+// bounded Pools (MaxSize > 0) knows it has room for another Put.
+func (p *Pools) decSize() {
+	p.decSizeN(1)
+}
+
+// decSizeN is decSize for n items at once.
+func (p *Pools) decSizeN(n int) {
+	if p.MaxSize > 0 && n > 0 {
+		atomic.AddInt32(&p.size, -int32(n))
 	}
-	return p.getSlow()
 }
 
 func (p *Pools) getSlow() (x interface{}) {
@@ -164,21 +416,50 @@ func (p *Pools) getSlow() (x interface{}) {
 	runtime_procUnpin()
 	for i := 0; i < int(size); i++ {
 		l := indexLocals(local, (pid+i+1)%int(size))
-		l.Lock()
-		last := len(l.shared) - 1
-		if last >= 0 {
-			x = l.shared[last]
-			l.shared = l.shared[:last]
-			l.Unlock()
-			break
+		if x, ok := l.shared.popTail(); ok {
+			p.decSize()
+			atomic.AddInt64(&p.statHits, 1)
+			atomic.AddInt64(&p.statSteals, 1)
+			return x
 		}
-		l.Unlock()
 	}
 
-	if x == nil && p.New != nil {
-		x = p.New()
+	// Try the victim cache: items that survived one GC cycle and have not
+	// yet been dropped. This is checked after stealing from fresh locals
+	// so that freshly Put items are preferred over the older generation.
+	size = atomic.LoadUintptr(&p.victimSize)
+	if int(size) == 0 {
+		return p.newValue()
 	}
-	return x
+	local = p.victim
+	if pid >= int(size) {
+		pid = 0
+	}
+	l := indexLocals(local, pid)
+	if n := l.privateLen; n > 0 {
+		n--
+		x = l.private[n]
+		l.private[n] = nil
+		l.privateLen = n
+	}
+	if x != nil {
+		p.decSize()
+		atomic.AddInt64(&p.statHits, 1)
+		atomic.AddInt64(&p.statSteals, 1)
+		return x
+	}
+	for i := 0; i < int(size); i++ {
+		l := indexLocals(local, (pid+i)%int(size))
+		if x, ok := l.shared.popTail(); ok {
+			p.decSize()
+			atomic.AddInt64(&p.statHits, 1)
+			atomic.AddInt64(&p.statSteals, 1)
+			return x
+		}
+	}
+	// victimSize is left as it is: other Ps' victim private buffers may
+	// still hold items, and the next cleanup passes those to OnEvict.
+	return p.newValue()
 }
 
 // Get selects an arbitrary item from the Pools, removes it from the
@@ -191,42 +472,45 @@ func (p *Pools) getSlow() (x interface{}) {
 // the result of calling p.New.
 func (p *Pools) Gets(xs []interface{}) int {
 	xsl := len(xs)
+	atomic.AddInt64(&p.statGets, int64(xsl))
 	if raceenabled {
 		if p.New != nil {
 			for i := 0; i < xsl; i++ {
-				xs[i] = p.New()
+				xs[i] = p.newValue()
 			}
 			return xsl
 		}
+		atomic.AddInt64(&p.statMisses, int64(xsl))
 		return 0
 	}
 
 	l := p.pin()
 	gxs := xs[:0]
-	if n := len(l.private); n >= xsl {
-		gxs = append(gxs, l.private[n-xsl:]...)
-		l.private = l.private[:n-xsl]
+	if n := l.privateLen; n >= xsl {
+		gxs = append(gxs, l.private[n-xsl:n]...)
+		for i := n - xsl; i < n; i++ {
+			l.private[i] = nil
+		}
+		l.privateLen = n - xsl
 	} else if n > 0 {
-		gxs = append(gxs, l.private...)
-		l.private = l.private[:0]
+		gxs = append(gxs, l.private[:n]...)
+		for i := 0; i < n; i++ {
+			l.private[i] = nil
+		}
+		l.privateLen = 0
 	}
 
+	for len(gxs) < xsl {
+		x, ok := l.shared.popHead()
+		if !ok {
+			break
+		}
+		gxs = append(gxs, x)
+	}
 	runtime_procUnpin()
 	gxsl := len(gxs)
-	if gxsl == xsl {
-		return xsl
-	}
-	l.Lock()
-
-	if n, lack := len(l.shared), xsl-gxsl; n >= lack {
-		gxs = append(gxs, l.shared[n-lack:]...)
-		l.shared = l.shared[:n-lack]
-	} else if n > 0 {
-		gxs = append(gxs, l.shared...)
-		l.shared = l.shared[:0]
-	}
-	l.Unlock()
-	gxsl = len(gxs)
+	p.decSizeN(gxsl)
+	atomic.AddInt64(&p.statHits, int64(gxsl))
 	if gxsl == xsl {
 		return xsl
 	}
@@ -243,29 +527,67 @@ func (p *Pools) getSlows(xs []interface{}) int {
 	// Try to steal one element from other procs.
 	pid := runtime_procPin()
 	runtime_procUnpin()
-	for i := 0; i < int(size); i++ {
+	for i := 0; i < int(size) && gxsl < xsl; i++ {
 		l := indexLocals(local, (pid+i+1)%int(size))
-		l.Lock()
-
-		if n, lack := len(l.shared), xsl-len(gxs); n >= lack {
-			gxs = append(gxs, l.shared[n-lack:]...)
-			l.shared = l.shared[:n-lack]
-		} else if n > 0 {
-			gxs = append(gxs, l.shared...)
-			l.shared = l.shared[:0]
+		for gxsl < xsl {
+			x, ok := l.shared.popTail()
+			if !ok {
+				break
+			}
+			gxs = append(gxs, x)
+			gxsl = len(gxs)
 		}
+	}
 
-		gxsl = len(gxs)
-		if gxsl == xsl {
-			l.Unlock()
-			break
+	// Fall back to the victim cache before allocating fresh items.
+	if gxsl < xsl {
+		vsize := atomic.LoadUintptr(&p.victimSize)
+		if vsize > 0 {
+			vlocal := p.victim
+			for i := 0; i < int(vsize) && gxsl < xsl; i++ {
+				l := indexLocals(vlocal, (pid+i)%int(vsize))
+				if n, lack := l.privateLen, xsl-gxsl; n >= lack {
+					gxs = append(gxs, l.private[n-lack:n]...)
+					for i := n - lack; i < n; i++ {
+						l.private[i] = nil
+					}
+					l.privateLen = n - lack
+				} else if n > 0 {
+					gxs = append(gxs, l.private[:n]...)
+					for i := 0; i < n; i++ {
+						l.private[i] = nil
+					}
+					l.privateLen = 0
+				}
+				gxsl = len(gxs)
+				for gxsl < xsl {
+					x, ok := l.shared.popTail()
+					if !ok {
+						break
+					}
+					gxs = append(gxs, x)
+					gxsl = len(gxs)
+				}
+			}
 		}
-		l.Unlock()
 	}
 
+	p.decSizeN(gxsl)
+	if gxsl > 0 {
+		atomic.AddInt64(&p.statHits, int64(gxsl))
+		atomic.AddInt64(&p.statSteals, int64(gxsl))
+	}
+	if gxsl < xsl {
+		atomic.AddInt64(&p.statMisses, int64(xsl-gxsl))
+	}
 	if gxsl < xsl && p.New != nil {
 		for i := gxsl; i < xsl; i++ {
-			gxs = append(gxs, p.New())
+			atomic.AddInt64(&p.statNewCalls, 1)
+			v := p.New()
+			if p.OnNew != nil {
+				p.OnNew(v)
+			}
+			gxs = append(gxs, v)
 		}
 		return xsl
 	} else {
@@ -317,29 +639,81 @@ func (p *Pools) pinSlow() *poolsLocal {
 func poolsCleanup() {
 	// This function is called with the world stopped, at the beginning of a garbage collection.
 	// It must not allocate and probably should not call any runtime functions.
-	// Defensively zero out everything, 2 reasons:
-	// 1. To prevent false retention of whole Pools.
-	// 2. If GC happens while a goroutine works with l.shared in Put/Get,
-	//    it will retain whole Pools. So next cycle memory consumption would be doubled.
-	for i, p := range allPoolxs {
-		allPoolxs[i] = nil
-		for i := 0; i < int(p.localSize); i++ {
-			l := indexLocals(p.local, i)
-			l.private = nil
-			for j := range l.shared {
-				l.shared[j] = nil
+	//
+	// Because the world is stopped, no pool user can be in a critical section
+	// that accesses the victim cache, so we don't need any synchronization for
+	// the operations below. This two-generation scheme gives every pooled
+	// item one extra GC cycle to be reused before it is dropped, trading a
+	// little extra retained memory for a much lower miss rate.
+	//
+	// oldPoolxs is exactly the set of Pools that had a local promoted to
+	// victim last cleanup, so their victim generation is dropped here
+	// unconditionally -- whether or not anything Got/Put since then, and
+	// regardless of whether the Pools itself is still in allPoolxs (it
+	// only re-enters allPoolxs the next time something pins it). Handling
+	// "drop old victim" and "promote local to victim" as two separate
+	// passes over two separate lists, the way upstream sync.Pool does, is
+	// what keeps these decoupled; merging them into a single pass over
+	// allPoolxs would silently stop dropping a Pools' victim the moment it
+	// goes untouched across a cleanup cycle.
+	var evictions []poolEviction
+	for _, p := range oldPoolxs {
+		// If the caller wants to know about resources it never got a
+		// chance to reclaim via Get, collect them here so they can be
+		// run through OnEvict once the world is running again; OnEvict
+		// is arbitrary user code (closing a file handle, freeing cgo
+		// state) that must not execute while everything else is halted.
+		if p.OnEvict != nil {
+			p.collectEvictions(p.victim, p.victimSize, &evictions)
+		} else if p.MaxSize > 0 {
+			// Nothing is evicted, but the dropped items still leave
+			// p.size so that a bounded Pools has room for later Puts.
+			for i := 0; i < int(p.victimSize); i++ {
+				l := indexLocals(p.victim, i)
+				p.decSizeN(l.privateLen + l.shared.len())
 			}
-			l.shared = nil
 		}
+		p.victim = nil
+		p.victimSize = 0
+	}
+	for _, p := range allPoolxs {
+		// Promote this cycle's locals to be the new victim cache.
+		p.victim = p.local
+		p.victimSize = p.localSize
 		p.local = nil
 		p.localSize = 0
 	}
-	allPoolxs = []*Pools{}
+	oldPoolxs, allPoolxs = allPoolxs, nil
+
+	if len(evictions) > 0 {
+		go flushEvictions(evictions)
+	}
+}
+
+// poolEviction pairs a value dropped from a Pools' victim generation with
+// the Pools it came from, so flushEvictions knows whose OnEvict to call.
+type poolEviction struct {
+	p *Pools
+	x interface{}
+}
+
+// flushEvictions runs every collected eviction's OnEvict callback. It is
+// started as its own goroutine by poolsCleanup so that OnEvict, which is
+// arbitrary caller code, never runs while the world is stopped.
+func flushEvictions(evictions []poolEviction) {
+	for _, e := range evictions {
+		e.p.OnEvict(e.x)
+	}
 }
 
 var (
 	allPoolxsMu Mutex
 	allPoolxs   []*Pools
+	// oldPoolxs is the allPoolxs list from the previous cleanup cycle
+	// (the Pools whose victim was promoted then), kept around so this
+	// cycle knows which victims to drop regardless of whether anything
+	// touched them in between.
+	oldPoolxs []*Pools
 )
 
 func init() {
@@ -354,6 +728,88 @@ func indexLocals(l unsafe.Pointer, i int) *poolsLocal {
 	return &(*[1000000]poolsLocal)(l)[i]
 }
 
+// collectEvictions drains every item still held across a [size]poolsLocal
+// array into *evictions for asynchronous eviction, decrementing p's size as
+// it goes. It is only called from poolsCleanup, with the world stopped, so
+// there is no concurrent pusher/popper to race with. Unlike a Drain, it
+// never calls p.OnEvict itself -- see flushEvictions.
+func (p *Pools) collectEvictions(local unsafe.Pointer, size uintptr, evictions *[]poolEviction) {
+	for i := 0; i < int(size); i++ {
+		l := indexLocals(local, i)
+		p.decSizeN(l.privateLen)
+		for j := 0; j < l.privateLen; j++ {
+			*evictions = append(*evictions, poolEviction{p, l.private[j]})
+			l.private[j] = nil
+		}
+		l.privateLen = 0
+		for {
+			x, ok := l.shared.popTail()
+			if !ok {
+				break
+			}
+			p.decSizeN(1)
+			*evictions = append(*evictions, poolEviction{p, x})
+		}
+	}
+}
+
+// Drain empties p immediately, without waiting for a GC cycle, instead of
+// relying on poolsCleanup. It drains every per-P shared chain (current
+// generation and victim) via the same lock-free popTail stealers use, plus
+// the calling goroutine's own per-P private buffer, pinned the same way
+// pin/pinSlow pin it for Put/Get. Other Ps' private buffers are left
+// alone: nothing but a stop-the-world GC can read or clear them without
+// racing the P that owns them, so they are reclaimed the usual way, over
+// the next two GC cycles. The victim generation's private buffers, the
+// calling P's included, are not drained either.
+//
+// If p.OnEvict is set, each drained item is passed to it instead of being
+// returned; otherwise Drain returns every drained item.
+func (p *Pools) Drain() []interface{} {
+	var out []interface{}
+	emit := func(x interface{}) {
+		p.decSize()
+		if p.OnEvict != nil {
+			p.OnEvict(x)
+			return
+		}
+		out = append(out, x)
+	}
+
+	l := p.pin()
+	private := make([]interface{}, l.privateLen)
+	copy(private, l.private[:l.privateLen])
+	for i := range l.private[:l.privateLen] {
+		l.private[i] = nil
+	}
+	l.privateLen = 0
+	runtime_procUnpin()
+	for _, x := range private {
+		emit(x)
+	}
+
+	drainShared := func(local unsafe.Pointer, size uintptr) {
+		for i := 0; i < int(size); i++ {
+			ll := indexLocals(local, i)
+			for {
+				x, ok := ll.shared.popTail()
+				if !ok {
+					break
+				}
+				emit(x)
+			}
+		}
+	}
+	localSize := atomic.LoadUintptr(&p.localSize)
+	local := p.local
+	drainShared(local, localSize)
+	victimSize := atomic.LoadUintptr(&p.victimSize)
+	victim := p.victim
+	drainShared(victim, victimSize)
+
+	return out
+}
+
 // Implemented in runtime.
 //func runtime_registerPoolCleanup(cleanup func())
 //func runtime_procPin() int
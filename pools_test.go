@@ -84,8 +84,16 @@ func TestPools(t *testing.T) {
 	p.Put("c")
 	debug.SetGCPercent(100) // to allow following GC to actually run
 	runtime.GC()
+	// "c" moved into the victim cache on this GC, so it survives one more cycle.
+	if g := p.Get(); g != "c" {
+		t.Fatalf("got %#v; want c from victim cache after one GC", g)
+	}
+
+	p.Put("c")
+	runtime.GC()
+	runtime.GC() // victim from the first GC is dropped on the second
 	if g := p.Get(); g != nil {
-		t.Fatalf("got %#v; want nil after GC", g)
+		t.Fatalf("got %#v; want nil after two GCs", g)
 	}
 
 	p.Puts(putXs1)
@@ -93,6 +101,7 @@ func TestPools(t *testing.T) {
 	getXs = []interface{}{nil, nil}
 	debug.SetGCPercent(100) // to allow following GC to actually run
 	runtime.GC()
+	runtime.GC()
 	if n := p.Gets(getXs); n != 0 || getXs[0] != nil || getXs[1] != nil {
 		t.Fatalf("got %#v; want [nil,nil]", getXs)
 	}
@@ -102,14 +111,27 @@ func TestPoolsPutGet(t *testing.T) {
 	// disable GC so we can control when it happens.
 	defer debug.SetGCPercent(debug.SetGCPercent(-1))
 	N := 10000 * 100
-	var p = Pools{PrivateSize: N}
+	var p Pools
+	seen := make([]bool, N)
 	for i := 0; i < N; i++ {
 		p.Put(i)
 	}
-	for i := N - 1; i > 0; i-- {
-		if n := p.Get(); n != i {
-			t.Fatalf("got %v; want %d", n, i)
+	// Put fills the fixed-size private buffer first and spills the rest to
+	// shared; Get always drains private before shared, so the overall order
+	// is no longer a single global LIFO once N exceeds poolLocalCap. Every
+	// value put in must still come back out exactly once.
+	for i := 0; i < N; i++ {
+		n, ok := p.Get().(int)
+		if !ok {
+			t.Fatalf("got non-int or nil at Get #%d", i)
+		}
+		if seen[n] {
+			t.Fatalf("got %v twice", n)
 		}
+		seen[n] = true
+	}
+	if v := p.Get(); v != nil {
+		t.Fatalf("got %v; want nil after draining all N values", v)
 	}
 }
 func TestPoolsNew(t *testing.T) {
@@ -138,6 +160,126 @@ func TestPoolsNew(t *testing.T) {
 	}
 }
 
+func TestPoolsGetNPutN(t *testing.T) {
+	// disable GC so we can control when it happens.
+	defer debug.SetGCPercent(debug.SetGCPercent(-1))
+	var p Pools
+	if p.GetN(100) != nil {
+		t.Fatal("expected empty")
+	}
+	buf := make([]byte, 100)
+	p.PutN(100, buf)
+	if g := p.GetN(60); g == nil || &g.([]byte)[0] != &buf[0] {
+		t.Fatalf("expected the size-100 buffer back for a size-60 request")
+	}
+	// A size-100 request should not be satisfied by a buffer put in for size 60.
+	p.PutN(60, make([]byte, 60))
+	if g := p.GetN(100); g != nil {
+		t.Fatalf("got %#v; want nil, size-60 bucket should not satisfy a size-100 request", g)
+	}
+}
+
+func TestPoolsGetNNewN(t *testing.T) {
+	calls := 0
+	p := Pools{NewN: func(size int) interface{} {
+		calls++
+		return make([]byte, size)
+	}}
+	g := p.GetN(10)
+	if calls != 1 {
+		t.Fatalf("got %d calls; want 1", calls)
+	}
+	if len(g.([]byte)) != 10 {
+		t.Fatalf("got len=%d; want 10", len(g.([]byte)))
+	}
+}
+
+func TestPoolsStats(t *testing.T) {
+	newCalls := 0
+	var onNewCalls int32
+	p := Pools{
+		New: func() interface{} {
+			newCalls++
+			return "new"
+		},
+		OnNew: func(x interface{}) {
+			atomic.AddInt32(&onNewCalls, 1)
+		},
+	}
+	if v := p.Get(); v != "new" {
+		t.Fatalf("got %v; want new", v)
+	}
+	p.Put("a")
+	if v := p.Get(); v != "a" {
+		t.Fatalf("got %v; want a", v)
+	}
+
+	s := p.Stats()
+	if s.Gets != 2 {
+		t.Fatalf("got Gets=%d; want 2", s.Gets)
+	}
+	if s.Puts != 1 {
+		t.Fatalf("got Puts=%d; want 1", s.Puts)
+	}
+	if s.Hits != 1 {
+		t.Fatalf("got Hits=%d; want 1", s.Hits)
+	}
+	if s.Misses != 1 {
+		t.Fatalf("got Misses=%d; want 1", s.Misses)
+	}
+	if s.NewCalls != 1 {
+		t.Fatalf("got NewCalls=%d; want 1", s.NewCalls)
+	}
+	if newCalls != 1 {
+		t.Fatalf("got %d calls to New; want 1", newCalls)
+	}
+	if got := atomic.LoadInt32(&onNewCalls); got != 1 {
+		t.Fatalf("got %d calls to OnNew; want 1", got)
+	}
+}
+
+func TestPoolsDrain(t *testing.T) {
+	var p Pools
+	const N = 64 // comfortably overflows the fixed-size private buffer into shared
+	for i := 0; i < N; i++ {
+		p.Put(i)
+	}
+	got := p.Drain()
+	if len(got) != N {
+		t.Fatalf("got %d drained items; want %d", len(got), N)
+	}
+	seen := make([]bool, N)
+	for _, x := range got {
+		seen[x.(int)] = true
+	}
+	for i := 0; i < N; i++ {
+		if !seen[i] {
+			t.Fatalf("item %d missing from Drain result", i)
+		}
+	}
+	if v := p.Get(); v != nil {
+		t.Fatalf("got %v; want nil after Drain", v)
+	}
+	if s := p.Stats(); s.PrivateLen != 0 || s.SharedLen != 0 {
+		t.Fatalf("got %+v; want PrivateLen=0 SharedLen=0 after Drain", s)
+	}
+}
+
+func TestPoolsDrainOnEvict(t *testing.T) {
+	var evicted []interface{}
+	p := Pools{OnEvict: func(x interface{}) {
+		evicted = append(evicted, x)
+	}}
+	p.Put("a")
+	p.Put("b")
+	if got := p.Drain(); got != nil {
+		t.Fatalf("got %v; want nil return when OnEvict is set", got)
+	}
+	if len(evicted) != 2 {
+		t.Fatalf("got %d evicted; want 2", len(evicted))
+	}
+}
+
 // Test that Pools does not hold pointers to previously cached resources.
 func TestPoolsGC(t *testing.T) {
 	testPools(t, true)
@@ -148,6 +290,83 @@ func TestPoolsRelease(t *testing.T) {
 	testPools(t, false)
 }
 
+func TestPoolsMaxSizeEvictsSurplus(t *testing.T) {
+	defer debug.SetGCPercent(debug.SetGCPercent(-1))
+	var evicted []interface{}
+	p := Pools{
+		MaxSize: 10,
+		OnEvict: func(x interface{}) {
+			evicted = append(evicted, x)
+		},
+	}
+	const N = 30
+	for i := 0; i < N; i++ {
+		p.Put(i)
+	}
+	if len(evicted) != N-10 {
+		t.Fatalf("got %d evicted; want %d", len(evicted), N-10)
+	}
+	kept := 0
+	for p.Get() != nil {
+		kept++
+	}
+	if kept != 10 {
+		t.Fatalf("got %d kept; want 10", kept)
+	}
+}
+
+// Test that items still held in a bounded Pools are released through
+// OnEvict (not just dropped) when the pool is drained by GC.
+func TestPoolsMaxSizeEvictsOnDrain(t *testing.T) {
+	defer debug.SetGCPercent(debug.SetGCPercent(-1))
+	var evicted uint32
+	p := Pools{
+		MaxSize: 1000,
+		OnEvict: func(x interface{}) {
+			atomic.AddUint32(&evicted, 1)
+		},
+	}
+	const N = 100
+	for i := 0; i < N; i++ {
+		v := new(string)
+		runtime.SetFinalizer(v, func(vv *string) {})
+		p.Put(v)
+	}
+	debug.SetGCPercent(100)
+	runtime.GC()
+	runtime.GC() // victim from the first GC is dropped (and evicted) on the second
+	// OnEvict runs on a goroutine started after the cleanup hook returns
+	// (see flushEvictions), not synchronously within the GC that drops the
+	// victim generation, so give it a moment to run.
+	var got uint32
+	for i := 0; i < 5; i++ {
+		if got = atomic.LoadUint32(&evicted); got == N {
+			return
+		}
+		time.Sleep(time.Duration(i*10+1) * time.Millisecond)
+	}
+	t.Fatalf("got %d evicted; want %d", got, N)
+}
+
+// Test that an item survives exactly one GC via the victim cache, and is
+// gone after a second consecutive GC.
+func TestPoolsVictimCache(t *testing.T) {
+	defer debug.SetGCPercent(debug.SetGCPercent(-1))
+	var p Pools
+	p.Put("v")
+	debug.SetGCPercent(100)
+	runtime.GC()
+	if g := p.Get(); g != "v" {
+		t.Fatalf("got %#v; want v surviving one GC via victim cache", g)
+	}
+	p.Put("v")
+	runtime.GC()
+	runtime.GC()
+	if g := p.Get(); g != nil {
+		t.Fatalf("got %#v; want nil, no item should survive two consecutive GCs", g)
+	}
+}
+
 func testPools(t *testing.T, drain bool) {
 	var p Pools
 	const N = 100
@@ -207,6 +426,86 @@ func TestPoolsStress(t *testing.T) {
 	}
 }
 
+// TestPoolsStressGOMAXPROCS runs concurrent Put/Get, including getSlow's
+// steals from another P's shared tail, while GOMAXPROCS changes mid-run.
+// Whenever GOMAXPROCS rises above the size of p.local, pinSlow reallocates
+// p.local while other goroutines are concurrently pushing, popping and
+// stealing.
+func TestPoolsStressGOMAXPROCS(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping in short mode")
+	}
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(0))
+
+	var p Pools
+	const G = 20
+	const N = 20000
+	done := make(chan bool, G)
+	for i := 0; i < G; i++ {
+		go func(i int) {
+			for j := 0; j < N; j++ {
+				p.Put(i)
+				if v := p.Get(); v != nil && v.(int) < 0 {
+					t.Errorf("got unexpected negative value %v", v)
+				}
+			}
+			done <- true
+		}(i)
+	}
+	for i := 0; i < 8; i++ {
+		runtime.GOMAXPROCS(1 + i%4)
+		time.Sleep(time.Millisecond)
+	}
+	for i := 0; i < G; i++ {
+		<-done
+	}
+}
+
+// TestPoolsStressConcurrentGC hammers Put/Get from many goroutines while a
+// separate goroutine repeatedly forces GC, so that poolsCleanup's victim
+// promotion (poolsCleanup in pools.go) races with pin/pinSlow and with
+// poolsChain pushes/pops/steals. It only checks that nothing panics, races,
+// or returns a value that was never Put.
+func TestPoolsStressConcurrentGC(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping in short mode")
+	}
+	var p Pools
+	const G = 20
+	const N = 20000
+	stop := make(chan struct{})
+	gcDone := make(chan bool)
+	go func() {
+		for {
+			select {
+			case <-stop:
+				gcDone <- true
+				return
+			default:
+				runtime.GC()
+			}
+		}
+	}()
+
+	done := make(chan bool, G)
+	for i := 0; i < G; i++ {
+		go func() {
+			for j := 0; j < N; j++ {
+				p.Put(j)
+				if v := p.Get(); v != nil && v.(int) < 0 {
+					t.Errorf("got unexpected negative value %v", v)
+				}
+			}
+			done <- true
+		}()
+	}
+	for i := 0; i < G; i++ {
+		<-done
+	}
+	close(stop)
+	<-gcDone
+}
+
 func enchmarkPoolsPutGet(b *testing.B) {
 	var p Pools
 	var v = 1
@@ -286,6 +585,36 @@ func BenchmarkPoolsPutGets_1024(b *testing.B) {
 	benchmarkPoolsPutGets(b, 10)
 }
 
+// benchmarkPoolsAcrossGC measures Get/Put throughput when a GC lands between
+// every batch, forcing every item through the victim cache (or oblivion, for
+// a pool that never had one) before the next batch can reuse it.
+func benchmarkPoolsAcrossGC(b *testing.B, gcEveryCycle bool) {
+	defer debug.SetGCPercent(debug.SetGCPercent(-1))
+	var p Pools
+	var v = 1
+	p.New = func() interface{} { return &v }
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for j := 0; j < 100; j++ {
+			p.Put(&v)
+		}
+		if gcEveryCycle {
+			runtime.GC()
+		}
+		for j := 0; j < 100; j++ {
+			p.Get()
+		}
+	}
+}
+
+func BenchmarkPoolsAcrossGC_NoGC(b *testing.B) {
+	benchmarkPoolsAcrossGC(b, false)
+}
+
+func BenchmarkPoolsAcrossGC_WithGC(b *testing.B) {
+	benchmarkPoolsAcrossGC(b, true)
+}
+
 func BenchmarkPoolsOverflow(b *testing.B) {
 	var p Pool
 	var v = 1
@@ -0,0 +1,127 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package sync
+
+import (
+	"reflect"
+	"sync/atomic"
+)
+
+// A TypedPool[T] is a generic wrapper around Pools that stores T directly,
+// so callers don't need interface{} type assertions at every Get/Put.
+//
+// If T is itself a pointer kind, a T value already fits in an interface{}
+// without allocating, so it is stored as-is. Otherwise (slices, structs,
+// scalars) Put boxes it into a newly allocated *T before handing it to the
+// underlying Pools, and Get copies the value back out of that *T. The *T is
+// not reused, so each Put of a non-pointer T allocates one *T, and each
+// Puts/Gets call allocates a []interface{} of len(xs).
+//
+// A TypedPool[T] is safe for use by multiple goroutines simultaneously, and
+// the zero value is ready to use.
+type TypedPool[T any] struct {
+	pools Pools
+
+	// New optionally specifies a function to generate a value when Get
+	// would otherwise return the zero value of T. It may not be changed
+	// concurrently with calls to Get.
+	New func() T
+
+	ptrKind int32 // atomic: 0 unknown, 1 T is a pointer kind, 2 it isn't
+}
+
+// isPointerKind reports whether T is a pointer kind, in which case values
+// can be stored in the underlying Pools directly instead of being boxed
+// into a *T. The result is cached after the first call.
+func (p *TypedPool[T]) isPointerKind() bool {
+	if v := atomic.LoadInt32(&p.ptrKind); v != 0 {
+		return v == 1
+	}
+	v := int32(2)
+	var zero T
+	if t := reflect.TypeOf(zero); t != nil && t.Kind() == reflect.Ptr {
+		v = 1
+	}
+	atomic.StoreInt32(&p.ptrKind, v)
+	return v == 1
+}
+
+// Get selects an arbitrary item from the pool, removes it, and returns it
+// to the caller. If the pool is empty and New is non-nil, Get returns the
+// result of calling New; otherwise it returns the zero value of T.
+func (p *TypedPool[T]) Get() T {
+	if x := p.pools.Get(); x != nil {
+		if p.isPointerKind() {
+			return x.(T)
+		}
+		v := x.(*T)
+		return *v
+	}
+	if p.New != nil {
+		return p.New()
+	}
+	var zero T
+	return zero
+}
+
+// Put adds x to the pool.
+func (p *TypedPool[T]) Put(x T) {
+	if p.isPointerKind() {
+		p.pools.Put(x)
+		return
+	}
+	v := new(T)
+	*v = x
+	p.pools.Put(v)
+}
+
+// Gets fills xs with items from the pool, falling back to New (or the zero
+// value, if New is nil) for any it cannot satisfy. It returns the number of
+// items filled from the pool.
+func (p *TypedPool[T]) Gets(xs []T) int {
+	n := len(xs)
+	if n == 0 {
+		return 0
+	}
+	ptrs := make([]interface{}, n)
+	got := p.pools.Gets(ptrs)
+	isPtr := p.isPointerKind()
+	for i := 0; i < got; i++ {
+		if isPtr {
+			xs[i] = ptrs[i].(T)
+		} else {
+			xs[i] = *ptrs[i].(*T)
+		}
+	}
+	for i := got; i < n; i++ {
+		if p.New != nil {
+			xs[i] = p.New()
+		} else {
+			var zero T
+			xs[i] = zero
+		}
+	}
+	return got
+}
+
+// Puts adds each element of xs to the pool.
+func (p *TypedPool[T]) Puts(xs []T) {
+	if len(xs) == 0 {
+		return
+	}
+	ptrs := make([]interface{}, len(xs))
+	if p.isPointerKind() {
+		for i := range xs {
+			ptrs[i] = xs[i]
+		}
+	} else {
+		for i := range xs {
+			v := new(T)
+			*v = xs[i]
+			ptrs[i] = v
+		}
+	}
+	p.pools.Puts(ptrs)
+}
@@ -0,0 +1,105 @@
+// Copyright 2013 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Pools is no-op under race detector, so all these tests do not work.
+// +build !race
+
+package sync_test
+
+import (
+	. "sync"
+	"testing"
+)
+
+func TestTypedPoolGetPut(t *testing.T) {
+	var p TypedPool[string]
+	if v := p.Get(); v != "" {
+		t.Fatalf("got %q; want empty", v)
+	}
+	p.Put("a")
+	p.Put("b")
+	if v := p.Get(); v != "b" {
+		t.Fatalf("got %q; want b", v)
+	}
+	if v := p.Get(); v != "a" {
+		t.Fatalf("got %q; want a", v)
+	}
+}
+
+func TestTypedPoolNew(t *testing.T) {
+	i := 0
+	p := TypedPool[int]{New: func() int { i++; return i }}
+	if v := p.Get(); v != 1 {
+		t.Fatalf("got %v; want 1", v)
+	}
+	p.Put(42)
+	if v := p.Get(); v != 42 {
+		t.Fatalf("got %v; want 42", v)
+	}
+	if v := p.Get(); v != 2 {
+		t.Fatalf("got %v; want 2", v)
+	}
+}
+
+func TestTypedPoolSlice(t *testing.T) {
+	var p TypedPool[[]byte]
+	p.Put(make([]byte, 8))
+	buf := p.Get()
+	if len(buf) != 8 {
+		t.Fatalf("got len=%d; want 8", len(buf))
+	}
+}
+
+func TestTypedPoolGetsPuts(t *testing.T) {
+	var p TypedPool[int]
+	p.Puts([]int{1, 2, 3})
+	xs := make([]int, 3)
+	if n := p.Gets(xs); n != 3 {
+		t.Fatalf("got n=%d; want 3", n)
+	}
+}
+
+func TestTypedPoolPointerKind(t *testing.T) {
+	var p TypedPool[*point]
+	p.Put(&point{1, 2})
+	v := p.Get()
+	if v == nil || v.X != 1 || v.Y != 2 {
+		t.Fatalf("got %+v; want &{1 2}", v)
+	}
+	if v := p.Get(); v != nil {
+		t.Fatalf("got %+v; want nil", v)
+	}
+
+	p.Puts([]*point{{3, 4}, {5, 6}})
+	xs := make([]*point, 2)
+	if n := p.Gets(xs); n != 2 {
+		t.Fatalf("got n=%d; want 2", n)
+	}
+}
+
+type point struct{ X, Y int }
+
+func BenchmarkTypedPoolPutsSlice(b *testing.B) {
+	var p TypedPool[[]byte]
+	xs := [][]byte{make([]byte, 16)}
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			p.Puts(xs)
+			p.Gets(xs)
+		}
+	})
+}
+
+func BenchmarkTypedPoolPutsStruct(b *testing.B) {
+	var p TypedPool[point]
+	xs := []point{{1, 2}}
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			p.Puts(xs)
+			p.Gets(xs)
+		}
+	})
+}